@@ -0,0 +1,246 @@
+// This file is part of arduino-cli.
+//
+// Copyright 2020 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the GNU General Public License version 3,
+// which covers the main part of arduino-cli.
+// The terms of this license can be found at:
+// https://www.gnu.org/licenses/gpl-3.0.en.html
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package lib
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/arduino/arduino-cli/commands/internal/instances"
+	"github.com/arduino/arduino-cli/internal/arduino/libraries/librariesindex"
+	"github.com/arduino/arduino-cli/internal/arduino/libraries/librariesmanager"
+	rpc "github.com/arduino/arduino-cli/rpc/cc/arduino/cli/commands/v1"
+	paths "github.com/arduino/go-paths-helper"
+)
+
+// downloadCacheDir returns the content-addressed cache directory used to make
+// library downloads resumable and offline-safe across repeated installs
+// (e.g. a CI matrix that installs the same libraries over and over).
+func downloadCacheDir(lm *librariesmanager.LibrariesManager) *paths.Path {
+	return lm.DownloadsDir.Parent().Join("cache", "libraries")
+}
+
+// gitCacheDir returns the cache directory for git-cloned libraries. Unlike
+// the index download cache, a git remote has no declared checksum to
+// content-address by before it is cloned, so entries here are keyed by a
+// hash of the source URL instead (see urlHashKey).
+func gitCacheDir(lm *librariesmanager.LibrariesManager) *paths.Path {
+	return lm.DownloadsDir.Parent().Join("cache", "git")
+}
+
+// urlHashKey derives a cache key for sources, such as a git remote, that
+// have no declared content checksum to address the cache by.
+func urlHashKey(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:])
+}
+
+// checksumCacheKey derives the cache filename for an archive from its
+// verified content checksum (the index's "SHA-256:<hex>" format), so the
+// cache is keyed by the archive's bytes rather than by the URL it happened
+// to come from: re-publishing different bytes at the same URL cannot serve
+// stale or tampered content from the cache, and identical bytes served from
+// different mirrors share one cache entry.
+func checksumCacheKey(checksum string) (string, error) {
+	algorithm, hash, found := strings.Cut(checksum, ":")
+	if !found || !strings.EqualFold(algorithm, "SHA-256") || hash == "" {
+		return "", fmt.Errorf(tr("unsupported or missing checksum %q", checksum))
+	}
+	return strings.ToLower(hash), nil
+}
+
+// populateFromCache copies a previously cached archive for libRelease into the
+// downloads directory, if present, so the normal download step can skip the
+// network round-trip entirely.
+func populateFromCache(lm *librariesmanager.LibrariesManager, libRelease *librariesindex.Release) error {
+	key, err := checksumCacheKey(libRelease.Resource.Checksum)
+	if err != nil {
+		return nil
+	}
+	cached := downloadCacheDir(lm).Join(key)
+	if cached.NotExist() {
+		return nil
+	}
+	return cached.CopyTo(lm.DownloadsDir.Join(libRelease.Resource.ArchiveFileName))
+}
+
+// saveToCache stores a freshly downloaded and verified archive in the
+// content-addressed cache so later installs can be served offline.
+func saveToCache(lm *librariesmanager.LibrariesManager, libRelease *librariesindex.Release) error {
+	src := lm.DownloadsDir.Join(libRelease.Resource.ArchiveFileName)
+	if src.NotExist() {
+		return nil
+	}
+	key, err := checksumCacheKey(libRelease.Resource.Checksum)
+	if err != nil {
+		return err
+	}
+	cacheDir := downloadCacheDir(lm)
+	if err := cacheDir.MkdirAll(); err != nil {
+		return err
+	}
+	return src.CopyTo(cacheDir.Join(key))
+}
+
+// LibraryCachePrefetch downloads the given libraries into the local
+// content-addressed cache without installing them, so an air-gapped build
+// machine can be warmed ahead of time and later exported with
+// LibraryCacheExport.
+func LibraryCachePrefetch(ctx context.Context, req *rpc.LibraryCachePrefetchRequest, downloadCB rpc.DownloadProgressCB, taskCB rpc.TaskProgressCB) error {
+	lm, err := instances.GetLibraryManager(req.GetInstance())
+	if err != nil {
+		return err
+	}
+
+	for _, name := range req.GetNames() {
+		libRelease, err := findLibraryIndexRelease(lm.Index, &rpc.LibraryInstallRequest{Name: name})
+		if err != nil {
+			return err
+		}
+		if err := populateFromCache(lm, libRelease); err != nil {
+			return err
+		}
+		if err := downloadLibrary(lm, libRelease, downloadCB, taskCB, "install"); err != nil {
+			return err
+		}
+		if err := verifyLibraryArchive(lm, libRelease, &rpc.LibraryInstallRequest{Instance: req.GetInstance()}, taskCB); err != nil {
+			return err
+		}
+		if err := saveToCache(lm, libRelease); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LibraryCacheExport packages the content-addressed cache into a single
+// tarball that can be copied to an air-gapped machine and restored there with
+// LibraryCacheImport.
+func LibraryCacheExport(ctx context.Context, req *rpc.LibraryCacheExportRequest) error {
+	lm, err := instances.GetLibraryManager(req.GetInstance())
+	if err != nil {
+		return err
+	}
+	return archiveDir(downloadCacheDir(lm), req.GetDestPath())
+}
+
+// LibraryCacheImport restores a cache tarball produced by LibraryCacheExport
+// into the local content-addressed cache.
+func LibraryCacheImport(ctx context.Context, req *rpc.LibraryCacheImportRequest) error {
+	lm, err := instances.GetLibraryManager(req.GetInstance())
+	if err != nil {
+		return err
+	}
+	return extractTarGz(req.GetArchivePath(), downloadCacheDir(lm))
+}
+
+// archiveDir writes a gzipped tar of srcDir to destPath.
+func archiveDir(srcDir *paths.Path, destPath string) error {
+	out, err := os.Create(destPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	gw := gzip.NewWriter(out)
+	defer gw.Close()
+	tw := tar.NewWriter(gw)
+	defer tw.Close()
+
+	return filepath.Walk(srcDir.String(), func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir.String(), path)
+		if err != nil {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = rel
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+}
+
+// extractTarGz unpacks the gzipped tar at archivePath into destDir.
+func extractTarGz(archivePath string, destDir *paths.Path) error {
+	in, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	gr, err := gzip.NewReader(in)
+	if err != nil {
+		return err
+	}
+	defer gr.Close()
+
+	if err := destDir.MkdirAll(); err != nil {
+		return err
+	}
+
+	tarReader := tar.NewReader(gr)
+	for {
+		hdr, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		cleanName := filepath.Clean(hdr.Name)
+		if cleanName == ".." || strings.HasPrefix(cleanName, ".."+string(filepath.Separator)) || filepath.IsAbs(cleanName) {
+			return fmt.Errorf(tr("archive entry %s escapes the destination directory", hdr.Name))
+		}
+		dest := destDir.Join(cleanName)
+		if err := dest.Parent().MkdirAll(); err != nil {
+			return err
+		}
+		out, err := os.OpenFile(dest.String(), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.FileMode(hdr.Mode))
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, tarReader); err != nil {
+			out.Close()
+			return err
+		}
+		out.Close()
+	}
+}
+
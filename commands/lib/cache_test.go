@@ -0,0 +1,138 @@
+// This file is part of arduino-cli.
+//
+// Copyright 2020 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the GNU General Public License version 3,
+// which covers the main part of arduino-cli.
+// The terms of this license can be found at:
+// https://www.gnu.org/licenses/gpl-3.0.en.html
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package lib
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	paths "github.com/arduino/go-paths-helper"
+)
+
+func TestChecksumCacheKey(t *testing.T) {
+	key, err := checksumCacheKey("SHA-256:DEADBEEF")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != "deadbeef" {
+		t.Errorf("got %q, want %q", key, "deadbeef")
+	}
+
+	if _, err := checksumCacheKey("MD5:deadbeef"); err == nil {
+		t.Error("expected an unsupported algorithm to be rejected")
+	}
+	if _, err := checksumCacheKey(""); err == nil {
+		t.Error("expected a missing checksum to be rejected")
+	}
+}
+
+func TestUrlHashKeyIsStableAndDistinct(t *testing.T) {
+	a := urlHashKey("https://example.com/foo.git")
+	b := urlHashKey("https://example.com/foo.git")
+	c := urlHashKey("https://example.com/bar.git")
+	if a != b {
+		t.Error("expected the same URL to hash to the same key")
+	}
+	if a == c {
+		t.Error("expected different URLs to hash to different keys")
+	}
+}
+
+// writeTarGz writes a gzipped tar containing a single entry with the given
+// name and content, used to exercise extractTarGz's tar-slip protection.
+func writeTarGz(t *testing.T, path, name string, content []byte) {
+	t.Helper()
+	out, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	gw := gzip.NewWriter(out)
+	defer gw.Close()
+	tw := tar.NewWriter(gw)
+	defer tw.Close()
+
+	hdr := &tar.Header{Name: name, Mode: 0o600, Size: int64(len(content))}
+	if err := tw.WriteHeader(hdr); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write(content); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtractTarGzRejectsTarSlip(t *testing.T) {
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "evil.tar.gz")
+	writeTarGz(t, archivePath, "../../etc/passwd", []byte("pwned"))
+
+	destDir := paths.New(filepath.Join(dir, "dest"))
+	if err := extractTarGz(archivePath, destDir); err == nil {
+		t.Error("expected a tar-slip entry to be rejected")
+	}
+}
+
+func TestExtractTarGzExtractsRegularEntries(t *testing.T) {
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "lib.tar.gz")
+	writeTarGz(t, archivePath, "mylib/library.properties", []byte("name=MyLib"))
+
+	destDir := paths.New(filepath.Join(dir, "dest"))
+	if err := extractTarGz(archivePath, destDir); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(destDir.Join("mylib", "library.properties").String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "name=MyLib" {
+		t.Errorf("got %q, want %q", content, "name=MyLib")
+	}
+}
+
+func TestArchiveDirExtractTarGzRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	srcDir := paths.New(filepath.Join(dir, "src"))
+	if err := srcDir.Join("sub").MkdirAll(); err != nil {
+		t.Fatal(err)
+	}
+	if err := srcDir.Join("sub", "file.txt").WriteFile([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	archivePath := filepath.Join(dir, "cache.tar.gz")
+	if err := archiveDir(srcDir, archivePath); err != nil {
+		t.Fatal(err)
+	}
+
+	destDir := paths.New(filepath.Join(dir, "dest"))
+	if err := extractTarGz(archivePath, destDir); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(destDir.Join("sub", "file.txt").String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "hello" {
+		t.Errorf("got %q, want %q", content, "hello")
+	}
+}
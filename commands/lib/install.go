@@ -19,6 +19,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
 
 	"github.com/arduino/arduino-cli/commands"
 	"github.com/arduino/arduino-cli/commands/cmderrors"
@@ -29,15 +31,16 @@ import (
 	rpc "github.com/arduino/arduino-cli/rpc/cc/arduino/cli/commands/v1"
 	"github.com/arduino/go-paths-helper"
 	"github.com/sirupsen/logrus"
+	semver "go.bug.st/relaxed-semver"
 )
 
-// LibraryInstall resolves the library dependencies, then downloads and installs the libraries into the install location.
-func LibraryInstall(ctx context.Context, req *rpc.LibraryInstallRequest, downloadCB rpc.DownloadProgressCB, taskCB rpc.TaskProgressCB) error {
-	lm, err := instances.GetLibraryManager(req.GetInstance())
-	if err != nil {
-		return err
-	}
-
+// resolveLibraryInstallPlan resolves the dependencies for req (unless
+// req.GetNoDeps() is set) and runs the prerequisite check for every library
+// that would need to be installed, without downloading or installing
+// anything. Libraries that are already up to date are included in the
+// result so callers (LibraryInstall, LibraryInstallPlan) can decide how to
+// report or skip them.
+func resolveLibraryInstallPlan(ctx context.Context, lm *librariesmanager.LibrariesManager, req *rpc.LibraryInstallRequest) (map[*librariesindex.Release]*librariesmanager.LibraryInstallPlan, error) {
 	toInstall := map[string]*rpc.LibraryDependencyStatus{}
 	installLocation := libraries.FromRPCLibraryInstallLocation(req.GetInstallLocation())
 	if req.GetNoDeps() {
@@ -53,7 +56,7 @@ func LibraryInstall(ctx context.Context, req *rpc.LibraryInstallRequest, downloa
 			DoNotUpdateInstalledLibraries: req.GetNoOverwrite(),
 		})
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		for _, dep := range res.GetDependencies() {
@@ -62,14 +65,13 @@ func LibraryInstall(ctx context.Context, req *rpc.LibraryInstallRequest, downloa
 					err := errors.New(
 						tr("two different versions of the library %[1]s are required: %[2]s and %[3]s",
 							dep.GetName(), dep.GetVersionRequired(), existingDep.GetVersionRequired()))
-					return &cmderrors.LibraryDependenciesResolutionFailedError{Cause: err}
+					return nil, &cmderrors.LibraryDependenciesResolutionFailedError{Cause: err}
 				}
 			}
 			toInstall[dep.GetName()] = dep
 		}
 	}
 
-	// Find the libReleasesToInstall to install
 	libReleasesToInstall := map[*librariesindex.Release]*librariesmanager.LibraryInstallPlan{}
 	for _, lib := range toInstall {
 		libRelease, err := findLibraryIndexRelease(lm.Index, &rpc.LibraryInstallRequest{
@@ -77,43 +79,155 @@ func LibraryInstall(ctx context.Context, req *rpc.LibraryInstallRequest, downloa
 			Version: lib.GetVersionRequired(),
 		})
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		installTask, err := lm.InstallPrerequisiteCheck(libRelease.Library.Name, libRelease.Version, installLocation)
 		if err != nil {
-			return err
-		}
-		if installTask.UpToDate {
-			taskCB(&rpc.TaskProgress{Message: tr("Already installed %s", libRelease), Completed: true})
-			continue
+			return nil, err
 		}
 
-		if req.GetNoOverwrite() {
+		if !installTask.UpToDate && req.GetNoOverwrite() {
 			if installTask.ReplacedLib != nil {
-				return fmt.Errorf(tr("Library %[1]s is already installed, but with a different version: %[2]s", libRelease, installTask.ReplacedLib))
+				return nil, fmt.Errorf(tr("Library %[1]s is already installed, but with a different version: %[2]s", libRelease, installTask.ReplacedLib))
 			}
 		}
 		libReleasesToInstall[libRelease] = installTask
 	}
+	return libReleasesToInstall, nil
+}
+
+// LibraryInstall resolves the library dependencies, then downloads and installs the libraries into the install location.
+func LibraryInstall(ctx context.Context, req *rpc.LibraryInstallRequest, downloadCB rpc.DownloadProgressCB, taskCB rpc.TaskProgressCB) error {
+	lm, err := instances.GetLibraryManager(req.GetInstance())
+	if err != nil {
+		return err
+	}
+	installLocation := libraries.FromRPCLibraryInstallLocation(req.GetInstallLocation())
+
+	libReleasesToInstall, err := resolveLibraryInstallPlan(ctx, lm, req)
+	if err != nil {
+		return err
+	}
+
+	if req.GetDryRun() {
+		for libRelease, installTask := range libReleasesToInstall {
+			switch {
+			case installTask.UpToDate:
+				taskCB(&rpc.TaskProgress{Message: tr("Already installed %s", libRelease), Completed: true})
+			case installTask.ReplacedLib != nil:
+				taskCB(&rpc.TaskProgress{Message: tr("Would replace %[1]s with %[2]s", installTask.ReplacedLib, libRelease), Completed: true})
+			default:
+				taskCB(&rpc.TaskProgress{Message: tr("Would install %s", libRelease), Completed: true})
+			}
+		}
+		return nil
+	}
 
 	for libRelease, installTask := range libReleasesToInstall {
-		// Checks if libRelease is the requested library and not a dependency
-		downloadReason := "depends"
-		if libRelease.GetName() == req.GetName() {
-			downloadReason = "install"
-			if installTask.ReplacedLib != nil {
-				downloadReason = "upgrade"
+		if installTask.UpToDate {
+			taskCB(&rpc.TaskProgress{Message: tr("Already installed %s", libRelease), Completed: true})
+			delete(libReleasesToInstall, libRelease)
+		}
+	}
+
+	maxConcurrentDownloads := int(req.GetMaxConcurrentDownloads())
+	if maxConcurrentDownloads <= 0 {
+		maxConcurrentDownloads = 1
+	}
+	newDownloadCB := rpc.SynchronizeDownloadProgressCB(downloadCB)
+	newTaskCB := rpc.SynchronizeTaskProgressCB(taskCB)
+	var nextTaskID int32
+
+	var installedMux sync.Mutex
+	installedReleases := make([]*librariesindex.Release, 0, len(libReleasesToInstall))
+
+	// Installation into a given target directory must happen one at a time, but
+	// downloads (and the extraction that precedes installLibrary) may run
+	// concurrently, bounded by maxConcurrentDownloads.
+	installMux := map[libraries.LibraryLocation]*sync.Mutex{}
+	installMuxFor := func(loc libraries.LibraryLocation) *sync.Mutex {
+		installedMux.Lock()
+		defer installedMux.Unlock()
+		if installMux[loc] == nil {
+			installMux[loc] = &sync.Mutex{}
+		}
+		return installMux[loc]
+	}
+
+	sem := make(chan struct{}, maxConcurrentDownloads)
+	var wg sync.WaitGroup
+	errs := make(chan error, len(libReleasesToInstall))
+
+	for libRelease, installTask := range libReleasesToInstall {
+		libRelease, installTask := libRelease, installTask
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			taskID := atomic.AddInt32(&nextTaskID, 1)
+			downloadCB := newDownloadCB(taskID)
+			taskCB := newTaskCB(taskID)
+
+			// Checks if libRelease is the requested library and not a dependency
+			downloadReason := "depends"
+			if libRelease.GetName() == req.GetName() {
+				downloadReason = "install"
+				if installTask.ReplacedLib != nil {
+					downloadReason = "upgrade"
+				}
+				if installLocation == libraries.IDEBuiltIn {
+					downloadReason += "-builtin"
+				}
 			}
-			if installLocation == libraries.IDEBuiltIn {
-				downloadReason += "-builtin"
+			if err := populateFromCache(lm, libRelease); err != nil {
+				errs <- err
+				return
 			}
-		}
-		if err := downloadLibrary(lm, libRelease, downloadCB, taskCB, downloadReason); err != nil {
+			if err := downloadLibrary(lm, libRelease, downloadCB, taskCB, downloadReason); err != nil {
+				errs <- err
+				return
+			}
+			if err := verifyLibraryArchive(lm, libRelease, req, taskCB); err != nil {
+				errs <- err
+				return
+			}
+			if err := saveToCache(lm, libRelease); err != nil {
+				errs <- err
+				return
+			}
+
+			mux := installMuxFor(installLocation)
+			mux.Lock()
+			err := installLibrary(lm, libRelease, installTask, taskCB)
+			mux.Unlock()
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			installedMux.Lock()
+			installedReleases = append(installedReleases, libRelease)
+			installedMux.Unlock()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
 			return err
 		}
-		if err := installLibrary(lm, libRelease, installTask, taskCB); err != nil {
-			return err
+	}
+
+	if lockfilePath := req.GetLockfilePath(); lockfilePath != "" {
+		entries, err := lockfileEntriesFor(lm, installedReleases)
+		if err != nil {
+			return fmt.Errorf(tr("writing lockfile: %s", err))
+		}
+		if err := writeLockfile(paths.New(lockfilePath), entries); err != nil {
+			return fmt.Errorf(tr("writing lockfile: %s", err))
 		}
 	}
 
@@ -143,28 +257,83 @@ func installLibrary(lm *librariesmanager.LibrariesManager, libRelease *libraries
 	return nil
 }
 
-// ZipLibraryInstall FIXMEDOC
+// ZipLibraryInstall installs a library from a local zip archive. The archive
+// is also saved into the same content-addressed cache LibraryInstall uses,
+// keyed by its own checksum, so a later LibraryInstall of an archive with
+// identical bytes (e.g. the same library re-published under a different
+// URL) can be served from the cache instead of the network. There is no
+// cache to consult beforehand: req.GetPath() already points at the bytes to
+// install, so there is no fetch to skip.
 func ZipLibraryInstall(ctx context.Context, req *rpc.ZipLibraryInstallRequest, taskCB rpc.TaskProgressCB) error {
 	lm, err := instances.GetLibraryManager(req.GetInstance())
 	if err != nil {
 		return err
 	}
-	if err := lm.InstallZipLib(ctx, paths.New(req.GetPath()), req.GetOverwrite()); err != nil {
+	zipPath := paths.New(req.GetPath())
+	if err := lm.InstallZipLib(ctx, zipPath, req.GetOverwrite()); err != nil {
 		return &cmderrors.FailedLibraryInstallError{Cause: err}
 	}
+
+	if checksum, err := archiveChecksum(zipPath.String()); err == nil {
+		cacheDir := downloadCacheDir(lm)
+		if cacheDir.MkdirAll() == nil {
+			_ = zipPath.CopyTo(cacheDir.Join(checksum))
+		}
+	}
+
 	taskCB(&rpc.TaskProgress{Message: tr("Library installed"), Completed: true})
 	return nil
 }
 
-// GitLibraryInstall FIXMEDOC
+// GitLibraryInstall installs a library cloned from a git remote. A git
+// remote has no declared checksum to content-address by before it is
+// cloned, so this consults and populates a separate cache keyed by a hash
+// of the remote URL (see gitCacheDir/urlHashKey): a cache hit restores the
+// previously cloned tree without touching the network at all, making
+// repeated installs of the same remote offline-capable.
 func GitLibraryInstall(ctx context.Context, req *rpc.GitLibraryInstallRequest, taskCB rpc.TaskProgressCB) error {
 	lm, err := instances.GetLibraryManager(req.GetInstance())
 	if err != nil {
 		return err
 	}
+
+	installLocation := libraries.FromRPCLibraryInstallLocation(rpc.LibraryInstallLocation_LIBRARY_INSTALL_LOCATION_UNSPECIFIED)
+	installTask, err := lm.InstallPrerequisiteCheck(libraryNameFromURI(req.GetUrl()), semver.ParseRelaxed(""), installLocation)
+	if err != nil {
+		return err
+	}
+	if installTask.ReplacedLib != nil && !req.GetOverwrite() {
+		return &cmderrors.FailedLibraryInstallError{
+			Cause: fmt.Errorf(tr("library %s is already installed", installTask.ReplacedLib)),
+		}
+	}
+	targetPath := installTask.TargetPath
+
+	cached := gitCacheDir(lm).Join(urlHashKey(req.GetUrl()) + ".tar.gz")
+	if cached.Exist() {
+		if targetPath.Exist() {
+			if err := targetPath.RemoveAll(); err != nil {
+				return &cmderrors.FailedLibraryInstallError{Cause: err}
+			}
+		}
+		if err := extractTarGz(cached.String(), targetPath); err != nil {
+			return &cmderrors.FailedLibraryInstallError{Cause: err}
+		}
+		taskCB(&rpc.TaskProgress{Message: tr("Library installed from cache"), Completed: true})
+		return nil
+	}
+
 	if err := lm.InstallGitLib(req.GetUrl(), req.GetOverwrite()); err != nil {
 		return &cmderrors.FailedLibraryInstallError{Cause: err}
 	}
+
+	if targetPath.Exist() {
+		cacheDir := gitCacheDir(lm)
+		if cacheDir.MkdirAll() == nil {
+			_ = archiveDir(targetPath, cached.String())
+		}
+	}
+
 	taskCB(&rpc.TaskProgress{Message: tr("Library installed"), Completed: true})
 	return nil
 }
@@ -0,0 +1,150 @@
+// This file is part of arduino-cli.
+//
+// Copyright 2020 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the GNU General Public License version 3,
+// which covers the main part of arduino-cli.
+// The terms of this license can be found at:
+// https://www.gnu.org/licenses/gpl-3.0.en.html
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package lib
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/arduino/arduino-cli/commands/cmderrors"
+	"github.com/arduino/arduino-cli/commands/internal/instances"
+	"github.com/arduino/arduino-cli/internal/arduino/libraries"
+	"github.com/arduino/arduino-cli/internal/arduino/libraries/librariesmanager"
+	rpc "github.com/arduino/arduino-cli/rpc/cc/arduino/cli/commands/v1"
+	paths "github.com/arduino/go-paths-helper"
+	semver "go.bug.st/relaxed-semver"
+)
+
+// LibraryInstallFromSource installs a library from a URI, dispatching to the
+// installer matching its scheme (arduino:, git+https:, git+ssh:, file:,
+// oci:, https:) instead of requiring a dedicated RPC per source kind.
+func LibraryInstallFromSource(ctx context.Context, req *rpc.LibraryInstallFromSourceRequest, downloadCB rpc.DownloadProgressCB, taskCB rpc.TaskProgressCB) error {
+	uri := req.GetUri()
+	scheme, rest, found := strings.Cut(uri, ":")
+	if !found {
+		return fmt.Errorf(tr("invalid library source URI: %s", uri))
+	}
+
+	switch scheme {
+	case "arduino":
+		name, version, _ := strings.Cut(rest, "@")
+		return LibraryInstall(ctx, &rpc.LibraryInstallRequest{
+			Instance:        req.GetInstance(),
+			Name:            name,
+			Version:         version,
+			InstallLocation: req.GetInstallLocation(),
+			NoOverwrite:     !req.GetOverwrite(),
+		}, downloadCB, taskCB)
+
+	case "git+https", "git+ssh":
+		return GitLibraryInstall(ctx, &rpc.GitLibraryInstallRequest{
+			Instance:  req.GetInstance(),
+			Url:       scheme[len("git+"):] + ":" + rest,
+			Overwrite: req.GetOverwrite(),
+		}, taskCB)
+
+	case "file":
+		path := paths.New(strings.TrimPrefix(rest, "//"))
+		if !path.IsDir() {
+			return ZipLibraryInstall(ctx, &rpc.ZipLibraryInstallRequest{
+				Instance:  req.GetInstance(),
+				Path:      path.String(),
+				Overwrite: req.GetOverwrite(),
+			}, taskCB)
+		}
+		return installFromRegisteredSource(req, scheme, uri, taskCB)
+
+	default:
+		return installFromRegisteredSource(req, scheme, uri, taskCB)
+	}
+}
+
+// installFromRegisteredSource dispatches to a LibrarySource registered for
+// scheme, for URI schemes that have no dedicated RPC of their own (oci:,
+// https:, and file: URIs pointing at a directory).
+func installFromRegisteredSource(req *rpc.LibraryInstallFromSourceRequest, scheme, uri string, taskCB rpc.TaskProgressCB) error {
+	lm, err := instances.GetLibraryManager(req.GetInstance())
+	if err != nil {
+		return err
+	}
+
+	source, ok := librariesmanager.LookupLibrarySource(scheme)
+	if !ok {
+		return fmt.Errorf(tr("unsupported library source scheme: %s", scheme))
+	}
+
+	installLocation := libraries.FromRPCLibraryInstallLocation(req.GetInstallLocation())
+	installTask, err := lm.InstallPrerequisiteCheck(libraryNameFromURI(uri), semver.ParseRelaxed(""), installLocation)
+	if err != nil {
+		return err
+	}
+	if installTask.ReplacedLib != nil && !req.GetOverwrite() {
+		return &cmderrors.FailedLibraryInstallError{
+			Cause: fmt.Errorf(tr("library %s is already installed", installTask.ReplacedLib)),
+		}
+	}
+	installPath := installTask.TargetPath
+
+	if err := source.Install(context.Background(), uri, installPath); err != nil {
+		return &cmderrors.FailedLibraryInstallError{Cause: err}
+	}
+	taskCB(&rpc.TaskProgress{Message: tr("Library installed"), Completed: true})
+	return nil
+}
+
+// libraryNameFromURI derives a directory-safe library name from uri's
+// repository/path stem, stripping the scheme, any trailing OCI tag
+// ("repo:tag") or digest ("repo@sha256:..."), and a trailing ".git" or
+// archive extension, so "oci://registry/org/mylib:1.0" and
+// "https://host/path/mylib.tar.gz" both yield "mylib" rather than the tag
+// or the archive's file extension.
+func libraryNameFromURI(uri string) string {
+	if i := strings.Index(uri, "://"); i >= 0 {
+		uri = uri[i+3:]
+	}
+	uri = strings.TrimSuffix(uri, "/")
+
+	if at := strings.LastIndex(uri, "@"); at >= 0 {
+		uri = uri[:at]
+	}
+	if slash := strings.LastIndex(uri, "/"); slash >= 0 {
+		if colon := strings.LastIndex(uri[slash:], ":"); colon >= 0 {
+			uri = uri[:slash+colon]
+		}
+	}
+
+	name := uri
+	if i := strings.LastIndex(uri, "/"); i >= 0 {
+		name = uri[i+1:]
+	}
+	name = strings.TrimSuffix(name, ".git")
+	return stripArchiveExt(name)
+}
+
+// archiveExtensions lists the archive suffixes libraryNameFromURI strips
+// from a bare file name, longest first so ".tar.gz" is matched before ".gz".
+var archiveExtensions = []string{".tar.gz", ".tar.bz2", ".tgz", ".zip", ".tar"}
+
+// stripArchiveExt removes a trailing archive extension from name, if any.
+func stripArchiveExt(name string) string {
+	for _, ext := range archiveExtensions {
+		if strings.HasSuffix(name, ext) {
+			return strings.TrimSuffix(name, ext)
+		}
+	}
+	return name
+}
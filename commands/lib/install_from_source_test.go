@@ -0,0 +1,36 @@
+// This file is part of arduino-cli.
+//
+// Copyright 2020 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the GNU General Public License version 3,
+// which covers the main part of arduino-cli.
+// The terms of this license can be found at:
+// https://www.gnu.org/licenses/gpl-3.0.en.html
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package lib
+
+import "testing"
+
+func TestLibraryNameFromURI(t *testing.T) {
+	tests := []struct{ uri, want string }{
+		{"oci://registry/org/mylib:1.0", "mylib"},
+		{"oci://registry/org/mylib@sha256:abcd1234", "mylib"},
+		{"oci://registry:5000/org/mylib:1.0", "mylib"},
+		{"https://host/path/mylib.tar.gz", "mylib"},
+		{"https://host/path/mylib.tgz", "mylib"},
+		{"https://host/path/mylib.zip", "mylib"},
+		{"file:///home/user/mylib", "mylib"},
+		{"file:///home/user/mylib/", "mylib"},
+	}
+	for _, tt := range tests {
+		if got := libraryNameFromURI(tt.uri); got != tt.want {
+			t.Errorf("libraryNameFromURI(%q) = %q, want %q", tt.uri, got, tt.want)
+		}
+	}
+}
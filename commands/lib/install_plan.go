@@ -0,0 +1,72 @@
+// This file is part of arduino-cli.
+//
+// Copyright 2020 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the GNU General Public License version 3,
+// which covers the main part of arduino-cli.
+// The terms of this license can be found at:
+// https://www.gnu.org/licenses/gpl-3.0.en.html
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package lib
+
+import (
+	"context"
+	"sort"
+
+	"github.com/arduino/arduino-cli/commands/internal/instances"
+	rpc "github.com/arduino/arduino-cli/rpc/cc/arduino/cli/commands/v1"
+)
+
+// LibraryInstallPlan resolves dependencies and runs the prerequisite checks
+// for req exactly as LibraryInstall would, but never downloads or installs
+// anything. It returns a structured plan that CI and IDEs can inspect before
+// committing to the actual install.
+func LibraryInstallPlan(ctx context.Context, req *rpc.LibraryInstallRequest) (*rpc.LibraryInstallPlanResponse, error) {
+	lm, err := instances.GetLibraryManager(req.GetInstance())
+	if err != nil {
+		return nil, err
+	}
+
+	libReleasesToInstall, err := resolveLibraryInstallPlan(ctx, lm, req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &rpc.LibraryInstallPlanResponse{}
+	for libRelease, installTask := range libReleasesToInstall {
+		action := &rpc.LibraryInstallPlanAction{
+			Library:      libRelease.Library.Name,
+			Version:      libRelease.Version.String(),
+			SourceUrl:    libRelease.Resource.URL,
+			DownloadSize: libRelease.Resource.Size,
+		}
+		switch {
+		case installTask.UpToDate:
+			action.Action = "already-installed"
+		case installTask.ReplacedLib == nil:
+			action.Action = "install"
+		case installTask.ReplacedLib.Version.LessThan(libRelease.Version):
+			action.Action = "upgrade"
+			action.ReplacedLibrary = installTask.ReplacedLib.String()
+		case installTask.ReplacedLib.Version.Equal(libRelease.Version):
+			action.Action = "replace"
+			action.ReplacedLibrary = installTask.ReplacedLib.String()
+		default:
+			action.Action = "downgrade"
+			action.ReplacedLibrary = installTask.ReplacedLib.String()
+		}
+		resp.Actions = append(resp.Actions, action)
+	}
+
+	// libReleasesToInstall is a map, so its iteration order is not
+	// deterministic; resp.Actions is consumed by CI gates that diff it across
+	// runs, so sort it by library name for a stable, reviewable output.
+	sort.Slice(resp.Actions, func(i, j int) bool { return resp.Actions[i].Library < resp.Actions[j].Library })
+	return resp, nil
+}
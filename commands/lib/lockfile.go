@@ -0,0 +1,161 @@
+// This file is part of arduino-cli.
+//
+// Copyright 2020 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the GNU General Public License version 3,
+// which covers the main part of arduino-cli.
+// The terms of this license can be found at:
+// https://www.gnu.org/licenses/gpl-3.0.en.html
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/arduino/arduino-cli/commands"
+	"github.com/arduino/arduino-cli/commands/cmderrors"
+	"github.com/arduino/arduino-cli/commands/internal/instances"
+	"github.com/arduino/arduino-cli/internal/arduino/libraries"
+	"github.com/arduino/arduino-cli/internal/arduino/libraries/librariesindex"
+	"github.com/arduino/arduino-cli/internal/arduino/libraries/librariesmanager"
+	rpc "github.com/arduino/arduino-cli/rpc/cc/arduino/cli/commands/v1"
+	paths "github.com/arduino/go-paths-helper"
+)
+
+// lockfileLibrary is a single pinned entry in a lockfile, identifying exactly
+// the library release that was installed and the archive it came from. Hash
+// is computed over the bytes of the archive that was actually installed
+// (not copied from the index), so a tampered or re-published archive at the
+// same URL is caught on the next LibraryInstallFromLockfile.
+type lockfileLibrary struct {
+	Name       string `json:"name"`
+	Version    string `json:"version"`
+	SourceType string `json:"source_type"`
+	SourceURL  string `json:"source_url"`
+	Hash       string `json:"hash"`
+}
+
+// lockfile is the on-disk format of the lockfile written by LibraryInstall and
+// consumed by LibraryInstallFromLockfile.
+type lockfile struct {
+	Libraries []lockfileLibrary `json:"libraries"`
+}
+
+// writeLockfile serializes entries to path as a machine-readable lockfile
+// (e.g. arduino-libs.lock), allowing later installs to reproduce exactly the
+// same set of libraries across machines and CI.
+func writeLockfile(path *paths.Path, entries []lockfileLibrary) error {
+	data, err := json.MarshalIndent(&lockfile{Libraries: entries}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return path.WriteFile(data)
+}
+
+// readLockfile parses a lockfile previously written by writeLockfile.
+func readLockfile(path *paths.Path) (*lockfile, error) {
+	data, err := path.ReadFile()
+	if err != nil {
+		return nil, err
+	}
+	lf := &lockfile{}
+	if err := json.Unmarshal(data, lf); err != nil {
+		return nil, fmt.Errorf(tr("invalid lockfile %[1]s: %[2]s", path, err))
+	}
+	return lf, nil
+}
+
+// LibraryInstallFromLockfile installs the exact set of libraries pinned in the
+// lockfile at req.GetLockfilePath(), refusing to resolve versions on its own
+// and failing if an archive's hash does not match what was recorded.
+func LibraryInstallFromLockfile(ctx context.Context, req *rpc.LibraryInstallFromLockfileRequest, downloadCB rpc.DownloadProgressCB, taskCB rpc.TaskProgressCB) error {
+	lm, err := instances.GetLibraryManager(req.GetInstance())
+	if err != nil {
+		return err
+	}
+
+	lf, err := readLockfile(paths.New(req.GetLockfilePath()))
+	if err != nil {
+		return err
+	}
+
+	installLocation := libraries.FromRPCLibraryInstallLocation(req.GetInstallLocation())
+	for _, entry := range lf.Libraries {
+		libRelease, err := findLibraryIndexRelease(lm.Index, &rpc.LibraryInstallRequest{
+			Name:    entry.Name,
+			Version: entry.Version,
+		})
+		if err != nil {
+			return err
+		}
+
+		installTask, err := lm.InstallPrerequisiteCheck(libRelease.Library.Name, libRelease.Version, installLocation)
+		if err != nil {
+			return err
+		}
+		if installTask.UpToDate {
+			taskCB(&rpc.TaskProgress{Message: tr("Already installed %s", libRelease), Completed: true})
+			continue
+		}
+
+		if err := downloadLibrary(lm, libRelease, downloadCB, taskCB, "install"); err != nil {
+			return err
+		}
+		archivePath := lm.DownloadsDir.Join(libRelease.Resource.ArchiveFileName)
+		if err := verifyChecksum(archivePath.String(), "SHA-256:"+entry.Hash); err != nil {
+			return &cmderrors.FailedLibraryInstallError{
+				Cause: fmt.Errorf(tr("lockfile hash mismatch for %[1]s: %[2]s", libRelease, err)),
+			}
+		}
+		if err := verifyLibraryArchive(lm, libRelease, &rpc.LibraryInstallRequest{Instance: req.GetInstance()}, taskCB); err != nil {
+			return err
+		}
+		if err := installLibrary(lm, libRelease, installTask, taskCB); err != nil {
+			return err
+		}
+	}
+
+	return commands.Init(&rpc.InitRequest{Instance: req.GetInstance()}, nil)
+}
+
+// lockfileEntriesFor builds the lockfile entries for a set of libraries that
+// have just been installed, hashing each archive still sitting in
+// lm.DownloadsDir rather than trusting the checksum the index claims for it.
+// libReleases is installed concurrently by LibraryInstall, so its order is
+// not deterministic between runs; entries are de-duplicated and sorted by
+// library name so that installing the same set of libraries always produces
+// a byte-identical lockfile, the same way npm and go.sum do.
+func lockfileEntriesFor(lm *librariesmanager.LibrariesManager, libReleases []*librariesindex.Release) ([]lockfileLibrary, error) {
+	seen := map[string]bool{}
+	entries := make([]lockfileLibrary, 0, len(libReleases))
+	for _, libRelease := range libReleases {
+		if seen[libRelease.Library.Name] {
+			continue
+		}
+		seen[libRelease.Library.Name] = true
+
+		archivePath := lm.DownloadsDir.Join(libRelease.Resource.ArchiveFileName)
+		hash, err := archiveChecksum(archivePath.String())
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, lockfileLibrary{
+			Name:       libRelease.Library.Name,
+			Version:    libRelease.Version.String(),
+			SourceType: "index",
+			SourceURL:  libRelease.Resource.URL,
+			Hash:       hash,
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries, nil
+}
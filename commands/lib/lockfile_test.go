@@ -0,0 +1,58 @@
+// This file is part of arduino-cli.
+//
+// Copyright 2020 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the GNU General Public License version 3,
+// which covers the main part of arduino-cli.
+// The terms of this license can be found at:
+// https://www.gnu.org/licenses/gpl-3.0.en.html
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package lib
+
+import (
+	"path/filepath"
+	"testing"
+
+	paths "github.com/arduino/go-paths-helper"
+)
+
+func TestWriteReadLockfileRoundTrip(t *testing.T) {
+	entries := []lockfileLibrary{
+		{Name: "Foo", Version: "1.0.0", SourceType: "index", SourceURL: "https://example.com/Foo-1.0.0.zip", Hash: "aaaa"},
+		{Name: "Bar", Version: "2.1.0", SourceType: "index", SourceURL: "https://example.com/Bar-2.1.0.zip", Hash: "bbbb"},
+	}
+
+	path := paths.New(filepath.Join(t.TempDir(), "arduino-libs.lock"))
+	if err := writeLockfile(path, entries); err != nil {
+		t.Fatal(err)
+	}
+
+	lf, err := readLockfile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lf.Libraries) != len(entries) {
+		t.Fatalf("got %d libraries, want %d", len(lf.Libraries), len(entries))
+	}
+	for i, entry := range entries {
+		if lf.Libraries[i] != entry {
+			t.Errorf("entry %d = %+v, want %+v", i, lf.Libraries[i], entry)
+		}
+	}
+}
+
+func TestReadLockfileRejectsInvalidJSON(t *testing.T) {
+	path := paths.New(filepath.Join(t.TempDir(), "arduino-libs.lock"))
+	if err := path.WriteFile([]byte("not json")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readLockfile(path); err == nil {
+		t.Error("expected an error reading an invalid lockfile")
+	}
+}
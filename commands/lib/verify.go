@@ -0,0 +1,166 @@
+// This file is part of arduino-cli.
+//
+// Copyright 2020 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the GNU General Public License version 3,
+// which covers the main part of arduino-cli.
+// The terms of this license can be found at:
+// https://www.gnu.org/licenses/gpl-3.0.en.html
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package lib
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/arduino/arduino-cli/commands/cmderrors"
+	"github.com/arduino/arduino-cli/internal/arduino/libraries/librariesindex"
+	"github.com/arduino/arduino-cli/internal/arduino/libraries/librariesmanager"
+	rpc "github.com/arduino/arduino-cli/rpc/cc/arduino/cli/commands/v1"
+)
+
+// verifyLibraryArchive checks the archive just downloaded for libRelease
+// against the checksum declared in the library index, and, if req asks for
+// it, against a signature made with one of req's trusted keys. It must be
+// called after downloadLibrary and before installLibrary, since a compromised
+// mirror or a MITM could otherwise inject arbitrary code into the sketchbook.
+func verifyLibraryArchive(lm *librariesmanager.LibrariesManager, libRelease *librariesindex.Release, req *rpc.LibraryInstallRequest, taskCB rpc.TaskProgressCB) error {
+	archivePath := lm.DownloadsDir.Join(libRelease.Resource.ArchiveFileName)
+
+	if err := verifyChecksum(archivePath.String(), libRelease.Resource.Checksum); err != nil {
+		return &cmderrors.FailedLibraryInstallError{Cause: err}
+	}
+	taskCB(&rpc.TaskProgress{Message: tr("Checksum verified for %s", libRelease)})
+
+	if req.GetRequireSignature() {
+		if err := downloadSignature(archivePath.String(), libRelease.Resource.URL); err != nil {
+			return &cmderrors.FailedLibraryInstallError{Cause: err}
+		}
+		if err := verifySignature(archivePath.String(), req.GetTrustedKeys()); err != nil {
+			return &cmderrors.FailedLibraryInstallError{Cause: err}
+		}
+		taskCB(&rpc.TaskProgress{Message: tr("Signature verified for %s", libRelease)})
+	}
+
+	return nil
+}
+
+// downloadSignature fetches the detached signature for archivePath from its
+// source URL plus a ".sig" suffix (e.g. "https://example.com/Foo-1.0.0.zip"
+// -> "https://example.com/Foo-1.0.0.zip.sig") and saves it next to the
+// archive, so verifySignature can find it at archivePath+".sig".
+//
+// The library index does not yet publish GPG or minisign signatures, so
+// this deliberately uses a lightweight raw-Ed25519 signature over a
+// base64-encoded ".sig" sidecar rather than either of those formats; this is
+// an interim scheme for installs where the caller controls both the archive
+// host and trusted_keys, not a replacement for a standard signing format.
+func downloadSignature(archivePath, archiveURL string) error {
+	resp, err := http.Get(archiveURL + ".sig")
+	if err != nil {
+		return fmt.Errorf(tr("downloading signature for %[1]s: %[2]s", archiveURL, err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf(tr("downloading signature for %[1]s: server returned %[2]s", archiveURL, resp.Status))
+	}
+
+	out, err := os.Create(archivePath + ".sig")
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	_, err = io.Copy(out, resp.Body)
+	return err
+}
+
+// verifyChecksum hashes the file at archivePath and compares it against
+// expectedChecksum, which must be in the "SHA-256:<hex>" format used by the
+// library index.
+func verifyChecksum(archivePath, expectedChecksum string) error {
+	algorithm, expectedHash, found := strings.Cut(expectedChecksum, ":")
+	if !found || !strings.EqualFold(algorithm, "SHA-256") {
+		return fmt.Errorf(tr("unsupported or missing checksum for %s", archivePath))
+	}
+
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+
+	if actualHash := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(actualHash, expectedHash) {
+		return fmt.Errorf(tr("checksum mismatch for %[1]s: expected %[2]s, got %[3]s", archivePath, expectedHash, actualHash))
+	}
+	return nil
+}
+
+// archiveChecksum hashes the file at archivePath and returns it as a plain
+// hex string, for callers (such as the lockfile writer) that need a content
+// hash rather than a comparison against the index's declared checksum.
+func archiveChecksum(archivePath string) (string, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// verifySignature checks archivePath's detached Ed25519 signature, expected
+// alongside it at archivePath+".sig" as a base64-encoded signature, against
+// the given set of trusted keys (base64-encoded Ed25519 public keys). It
+// succeeds as soon as one trusted key verifies the signature.
+func verifySignature(archivePath string, trustedKeys []string) error {
+	if len(trustedKeys) == 0 {
+		return fmt.Errorf(tr("signature required for %s but no trusted keys were provided", archivePath))
+	}
+
+	sig, err := os.ReadFile(archivePath + ".sig")
+	if err != nil {
+		return fmt.Errorf(tr("reading signature for %[1]s: %[2]s", archivePath, err))
+	}
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil {
+		return fmt.Errorf(tr("invalid signature for %s: not valid base64", archivePath))
+	}
+
+	archive, err := os.ReadFile(archivePath)
+	if err != nil {
+		return err
+	}
+
+	for _, trustedKey := range trustedKeys {
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(trustedKey))
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			continue
+		}
+		if ed25519.Verify(ed25519.PublicKey(key), archive, signature) {
+			return nil
+		}
+	}
+	return fmt.Errorf(tr("signature for %s does not match any trusted key", archivePath))
+}
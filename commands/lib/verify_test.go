@@ -0,0 +1,99 @@
+// This file is part of arduino-cli.
+//
+// Copyright 2020 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the GNU General Public License version 3,
+// which covers the main part of arduino-cli.
+// The terms of this license can be found at:
+// https://www.gnu.org/licenses/gpl-3.0.en.html
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package lib
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTempArchive(t *testing.T, content []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "archive.zip")
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	path := writeTempArchive(t, []byte("library contents"))
+	hash, err := archiveChecksum(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := verifyChecksum(path, "SHA-256:"+hash); err != nil {
+		t.Errorf("expected checksum to verify, got: %s", err)
+	}
+	if err := verifyChecksum(path, "SHA-256:deadbeef"); err == nil {
+		t.Error("expected checksum mismatch to be detected")
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	path := writeTempArchive(t, []byte("library contents"))
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := ed25519.Sign(priv, []byte("library contents"))
+	if err := os.WriteFile(path+".sig", []byte(base64.StdEncoding.EncodeToString(signature)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	trustedKey := base64.StdEncoding.EncodeToString(pub)
+
+	if err := verifySignature(path, []string{trustedKey}); err != nil {
+		t.Errorf("expected signature to verify against its own key, got: %s", err)
+	}
+
+	otherPub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := verifySignature(path, []string{base64.StdEncoding.EncodeToString(otherPub)}); err == nil {
+		t.Error("expected signature verification to fail against an untrusted key")
+	}
+
+	if err := verifySignature(path, nil); err == nil {
+		t.Error("expected signature verification to fail when no trusted keys are given")
+	}
+}
+
+func TestVerifySignatureDetectsTamperedArchive(t *testing.T) {
+	path := writeTempArchive(t, []byte("library contents"))
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := ed25519.Sign(priv, []byte("library contents"))
+	if err := os.WriteFile(path+".sig", []byte(base64.StdEncoding.EncodeToString(signature)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Tamper with the archive after it was signed.
+	if err := os.WriteFile(path, []byte("malicious contents"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	trustedKey := base64.StdEncoding.EncodeToString(pub)
+	if err := verifySignature(path, []string{trustedKey}); err == nil {
+		t.Error("expected verification of a tampered archive to fail")
+	}
+}
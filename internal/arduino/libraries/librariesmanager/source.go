@@ -0,0 +1,51 @@
+// This file is part of arduino-cli.
+//
+// Copyright 2020 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the GNU General Public License version 3,
+// which covers the main part of arduino-cli.
+// The terms of this license can be found at:
+// https://www.gnu.org/licenses/gpl-3.0.en.html
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package librariesmanager
+
+import (
+	"context"
+
+	paths "github.com/arduino/go-paths-helper"
+)
+
+// LibrarySource installs a library from a specific kind of location, such as
+// an HTTP(S) tarball, a local directory, or an OCI registry. It lets new
+// install methods be added without growing a dedicated RPC per source.
+type LibrarySource interface {
+	// Scheme is the URI scheme this source handles, e.g. "https" or "oci".
+	Scheme() string
+	// Install fetches the library identified by uri and unpacks it at installPath.
+	Install(ctx context.Context, uri string, installPath *paths.Path) error
+}
+
+// librarySources holds the LibrarySource implementations registered for each
+// URI scheme.
+var librarySources = map[string]LibrarySource{}
+
+// RegisterLibrarySource makes source available under the scheme it declares,
+// overriding any source previously registered for that scheme. Built-in
+// sources register themselves in this package's init(); embedders can
+// register additional ones (e.g. for an internal OCI registry) at startup.
+func RegisterLibrarySource(source LibrarySource) {
+	librarySources[source.Scheme()] = source
+}
+
+// LookupLibrarySource returns the LibrarySource registered for scheme, or
+// false if no source handles it.
+func LookupLibrarySource(scheme string) (LibrarySource, bool) {
+	source, ok := librarySources[scheme]
+	return source, ok
+}
@@ -0,0 +1,231 @@
+// This file is part of arduino-cli.
+//
+// Copyright 2020 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the GNU General Public License version 3,
+// which covers the main part of arduino-cli.
+// The terms of this license can be found at:
+// https://www.gnu.org/licenses/gpl-3.0.en.html
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package librariesmanager
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	paths "github.com/arduino/go-paths-helper"
+)
+
+func init() {
+	RegisterLibrarySource(&localDirLibrarySource{})
+	RegisterLibrarySource(&httpTarballLibrarySource{})
+	RegisterLibrarySource(&ociLibrarySource{})
+}
+
+// localDirLibrarySource (scheme "file") installs a library by symlinking a
+// local directory into the sketchbook, so edits made in place are picked up
+// without reinstalling. This is meant for library development.
+type localDirLibrarySource struct{}
+
+func (s *localDirLibrarySource) Scheme() string { return "file" }
+
+func (s *localDirLibrarySource) Install(ctx context.Context, uri string, installPath *paths.Path) error {
+	src := paths.New(strings.TrimPrefix(uri, "file://"))
+	if !src.IsDir() {
+		return fmt.Errorf("%s is not a directory", src)
+	}
+	if installPath.Exist() {
+		if err := installPath.RemoveAll(); err != nil {
+			return err
+		}
+	}
+	return os.Symlink(src.String(), installPath.String())
+}
+
+// httpTarballLibrarySource (scheme "https") installs a library from a plain
+// HTTP(S) gzipped-tar URL, for teams that host libraries outside the
+// Arduino library index.
+type httpTarballLibrarySource struct{}
+
+func (s *httpTarballLibrarySource) Scheme() string { return "https" }
+
+func (s *httpTarballLibrarySource) Install(ctx context.Context, uri string, installPath *paths.Path) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("downloading %s: server returned %s", uri, resp.Status)
+	}
+	return extractTarGzTo(resp.Body, installPath)
+}
+
+// ociLibrarySource (scheme "oci") installs a library published as an OCI
+// artifact, letting organizations distribute private libraries through the
+// same registries they already use for container images. uri has the form
+// "oci://registry/repository:tag" and the artifact's last layer is expected
+// to be a gzipped tar of the library sources.
+type ociLibrarySource struct{}
+
+func (s *ociLibrarySource) Scheme() string { return "oci" }
+
+func (s *ociLibrarySource) Install(ctx context.Context, uri string, installPath *paths.Path) error {
+	registry, repository, reference, err := parseOCIReference(uri)
+	if err != nil {
+		return err
+	}
+
+	manifest, err := fetchOCIManifest(ctx, registry, repository, reference)
+	if err != nil {
+		return err
+	}
+	if len(manifest.Layers) == 0 {
+		return fmt.Errorf("OCI artifact %s has no layers", uri)
+	}
+	digest := manifest.Layers[len(manifest.Layers)-1].Digest
+
+	blob, err := fetchOCIBlob(ctx, registry, repository, digest)
+	if err != nil {
+		return err
+	}
+	defer blob.Close()
+	return extractTarGzTo(blob, installPath)
+}
+
+// ociManifest is the minimal subset of the OCI image manifest
+// (https://github.com/opencontainers/image-spec/blob/main/manifest.md) we
+// need to locate the layer carrying the library archive.
+type ociManifest struct {
+	Layers []struct {
+		Digest string `json:"digest"`
+	} `json:"layers"`
+}
+
+// parseOCIReference splits an "oci://registry/repository:tag" (or
+// "...@digest") URI into its registry, repository and reference parts.
+func parseOCIReference(uri string) (registry, repository, reference string, err error) {
+	rest := strings.TrimPrefix(uri, "oci://")
+	slash := strings.Index(rest, "/")
+	if slash < 0 {
+		return "", "", "", fmt.Errorf("invalid OCI reference: %s", uri)
+	}
+	registry, rest = rest[:slash], rest[slash+1:]
+
+	if at := strings.LastIndex(rest, "@"); at >= 0 {
+		return registry, rest[:at], rest[at+1:], nil
+	}
+	if colon := strings.LastIndex(rest, ":"); colon >= 0 {
+		return registry, rest[:colon], rest[colon+1:], nil
+	}
+	return registry, rest, "latest", nil
+}
+
+// fetchOCIManifest retrieves and decodes the manifest for repository:reference
+// from registry, following the OCI Distribution Specification.
+func fetchOCIManifest(ctx context.Context, registry, repository, reference string) (*ociManifest, error) {
+	url := fmt.Sprintf("https://%s/v2/%s/manifests/%s", registry, repository, reference)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.oci.image.manifest.v1+json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching manifest for %s/%s:%s: server returned %s", registry, repository, reference, resp.Status)
+	}
+
+	manifest := &ociManifest{}
+	if err := json.NewDecoder(resp.Body).Decode(manifest); err != nil {
+		return nil, fmt.Errorf("decoding manifest for %s/%s:%s: %s", registry, repository, reference, err)
+	}
+	return manifest, nil
+}
+
+// fetchOCIBlob opens a streaming read of the blob identified by digest in
+// repository on registry. The caller must close the returned reader.
+func fetchOCIBlob(ctx context.Context, registry, repository, digest string) (io.ReadCloser, error) {
+	url := fmt.Sprintf("https://%s/v2/%s/blobs/%s", registry, repository, digest)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("fetching blob %s from %s/%s: server returned %s", digest, registry, repository, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// extractTarGzTo unpacks the gzipped tar read from r into destDir, rejecting
+// any entry whose name would escape destDir.
+func extractTarGzTo(r io.Reader, destDir *paths.Path) error {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gr.Close()
+
+	if err := destDir.MkdirAll(); err != nil {
+		return err
+	}
+
+	tarReader := tar.NewReader(gr)
+	for {
+		hdr, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		cleanName := filepath.Clean(hdr.Name)
+		if cleanName == ".." || strings.HasPrefix(cleanName, ".."+string(filepath.Separator)) || filepath.IsAbs(cleanName) {
+			return fmt.Errorf("archive entry %s escapes the destination directory", hdr.Name)
+		}
+		dest := destDir.Join(cleanName)
+		if err := dest.Parent().MkdirAll(); err != nil {
+			return err
+		}
+		out, err := os.OpenFile(dest.String(), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.FileMode(hdr.Mode))
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, tarReader); err != nil {
+			out.Close()
+			return err
+		}
+		out.Close()
+	}
+}
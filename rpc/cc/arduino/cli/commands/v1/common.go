@@ -17,6 +17,7 @@ package commands
 
 import (
 	"sort"
+	"sync"
 
 	semver "go.bug.st/relaxed-semver"
 )
@@ -63,6 +64,41 @@ func (d DownloadProgressCB) End(success bool, message string) {
 // TaskProgressCB is a callback to receive progress messages
 type TaskProgressCB func(msg *TaskProgress)
 
+// SynchronizeDownloadProgressCB wraps cb with a mutex so it can be safely
+// invoked by multiple goroutines at once, e.g. when downloads are running
+// concurrently. Each call to the returned function yields a new
+// DownloadProgressCB bound to taskID, which it stamps on every message it
+// forwards, so a client driving several downloads at once can attribute
+// progress to the right one instead of seeing an interleaved, unattributable
+// stream.
+func SynchronizeDownloadProgressCB(cb DownloadProgressCB) func(taskID int32) DownloadProgressCB {
+	var mux sync.Mutex
+	return func(taskID int32) DownloadProgressCB {
+		return func(p *DownloadProgress) {
+			p.TaskId = taskID
+			mux.Lock()
+			defer mux.Unlock()
+			cb(p)
+		}
+	}
+}
+
+// SynchronizeTaskProgressCB wraps cb with a mutex so it can be safely invoked
+// by multiple goroutines at once, e.g. when installs are running
+// concurrently. It mirrors SynchronizeDownloadProgressCB, stamping taskID on
+// every TaskProgress message the returned callback forwards.
+func SynchronizeTaskProgressCB(cb TaskProgressCB) func(taskID int32) TaskProgressCB {
+	var mux sync.Mutex
+	return func(taskID int32) TaskProgressCB {
+		return func(p *TaskProgress) {
+			p.TaskId = taskID
+			mux.Lock()
+			defer mux.Unlock()
+			cb(p)
+		}
+	}
+}
+
 // InstanceCommand is an interface that represents a gRPC command with
 // a gRPC Instance.
 type InstanceCommand interface {